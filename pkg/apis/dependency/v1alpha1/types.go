@@ -0,0 +1,88 @@
+// Package v1alpha1 contains the Dependency custom resource used to persist
+// edges discovered by the gateway dependency learner.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Dependency records the destinations a single source identity has been
+// observed talking to. One Dependency exists per source SPIFFE identity.
+type Dependency struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DependencySpec   `json:"spec,omitempty"`
+	Status DependencyStatus `json:"status,omitempty"`
+}
+
+// DependencySpec is the observed edge set for a source identity.
+type DependencySpec struct {
+	// SourceIdentity is the SPIFFE ID of the workload these edges were
+	// observed from, e.g. spiffe://cluster.local/ns/foo/sa/default.
+	SourceIdentity string `json:"sourceIdentity"`
+
+	// Destinations is the set of hosts/ports this source has been seen
+	// calling, keyed by host+port at upsert time.
+	Destinations []DependencyDestination `json:"destinations,omitempty"`
+
+	// Mode controls whether pkg/policygen generates Istio policy from this
+	// Dependency's observed edges. Empty is equivalent to ModeObserve.
+	Mode LearnerMode `json:"mode,omitempty"`
+}
+
+// LearnerMode selects how observed edges are acted on.
+type LearnerMode string
+
+const (
+	// ModeObserve only learns edges; no policy is generated.
+	ModeObserve LearnerMode = "observe"
+
+	// ModeDryRun generates AuthorizationPolicies annotated
+	// l7router/dryrun=true with action AUDIT, so denies are logged without
+	// being enforced.
+	ModeDryRun LearnerMode = "dryrun"
+
+	// ModeEnforce generates AuthorizationPolicies with action ALLOW that
+	// whitelist only observed edges, denying everything else by omission.
+	ModeEnforce LearnerMode = "enforce"
+)
+
+// DependencyDestination is a single observed destination edge.
+type DependencyDestination struct {
+	// Host is the upstream cluster's service host, e.g.
+	// server.server-ns.svc.cluster.local.
+	Host string `json:"host"`
+
+	// Port is the upstream port that was dialed.
+	Port uint32 `json:"port"`
+
+	// FirstSeen is when this edge was first observed.
+	FirstSeen metav1.Time `json:"firstSeen"`
+
+	// LastSeen is when this edge was most recently observed.
+	LastSeen metav1.Time `json:"lastSeen"`
+
+	// SampleCount is the number of requests folded into this edge since
+	// FirstSeen.
+	SampleCount int64 `json:"sampleCount"`
+}
+
+// DependencyStatus records controller bookkeeping for a Dependency.
+type DependencyStatus struct {
+	// LastReconciled is the last time the controller successfully upserted
+	// this resource.
+	LastReconciled metav1.Time `json:"lastReconciled,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DependencyList is a list of Dependency resources.
+type DependencyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Dependency `json:"items"`
+}
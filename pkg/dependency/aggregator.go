@@ -0,0 +1,64 @@
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// edgeReport is the body the gateway-side aggregator sidecar POSTs for every
+// detected-dependency header it observes.
+type edgeReport struct {
+	// HeaderValue is the raw `detected-dependency` response header value.
+	HeaderValue string `json:"headerValue"`
+}
+
+// Aggregator is an HTTP sink that receives edge reports forwarded from the
+// gateway WasmPlugin (via an access-log sidecar or the plugin's own HTTP
+// dispatcher) and feeds them to a Controller for batching.
+type Aggregator struct {
+	edges  chan<- Edge
+	filter *IdentityFilter
+}
+
+// NewAggregator returns an Aggregator that publishes parsed edges onto edges.
+// The channel should be read by a Controller's Run loop. filter may be nil,
+// in which case every source identity is captured; when set, it is applied
+// defensively in case the gateway's own included/excluded identity
+// enforcement didn't already drop the edge.
+func NewAggregator(edges chan<- Edge, filter *IdentityFilter) *Aggregator {
+	return &Aggregator{edges: edges, filter: filter}
+}
+
+// ServeHTTP implements http.Handler, accepting POST /edges requests
+// containing a JSON-encoded edgeReport.
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var report edgeReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, fmt.Sprintf("decoding edge report: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	edge, err := ParseHeaderValue(report.HeaderValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !a.filter.Allows(edge.SourceIdentity) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	select {
+	case a.edges <- edge:
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+		http.Error(w, "request cancelled", http.StatusRequestTimeout)
+	}
+}
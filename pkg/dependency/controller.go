@@ -0,0 +1,185 @@
+package dependency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dependencyv1alpha1 "github.com/wtzhang23/l7router/pkg/apis/dependency/v1alpha1"
+)
+
+// DefaultBatchWindow is how long the Controller accumulates edges for a
+// source identity before upserting its Dependency resource.
+const DefaultBatchWindow = 5 * time.Second
+
+// Controller batches Edges reported by an Aggregator and upserts them into
+// per-source-identity Dependency resources.
+type Controller struct {
+	client      client.Client
+	namespace   string
+	window      time.Duration
+	filter      *IdentityFilter
+	defaultMode dependencyv1alpha1.LearnerMode
+
+	// pending accumulates edges for a source identity until they're
+	// flushed to the API server.
+	pending map[string]map[string]*dependencyv1alpha1.DependencyDestination
+}
+
+// NewController returns a Controller that upserts Dependency resources into
+// namespace using c, batching edges over window. filter may be nil, in which
+// case every source identity observed is persisted; when set, it is applied
+// defensively as a second check in case a matching edge reached the
+// Controller through a path that bypassed an Aggregator's own filtering.
+// defaultMode is stamped onto newly-created Dependency resources, controlling
+// whether pkg/policygen generates AuthorizationPolicies from their edges; it
+// has no effect on a Dependency that already exists, so an operator can
+// change an individual Dependency's mode without the Controller overwriting
+// it on the next flush.
+func NewController(c client.Client, namespace string, window time.Duration, filter *IdentityFilter, defaultMode dependencyv1alpha1.LearnerMode) *Controller {
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+	return &Controller{
+		client:      c,
+		namespace:   namespace,
+		window:      window,
+		filter:      filter,
+		defaultMode: defaultMode,
+		pending:     make(map[string]map[string]*dependencyv1alpha1.DependencyDestination),
+	}
+}
+
+// Run consumes edges until ctx is cancelled or edges is closed, flushing
+// accumulated batches to the API server every Controller.window.
+func (c *Controller) Run(ctx context.Context, edges <-chan Edge) error {
+	ticker := time.NewTicker(c.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := c.flush(ctx); err != nil {
+				return fmt.Errorf("dependency: flushing batch: %w", err)
+			}
+		case edge, ok := <-edges:
+			if !ok {
+				return c.flush(ctx)
+			}
+			c.record(edge)
+		}
+	}
+}
+
+// record folds edge into the in-memory batch for its source identity, unless
+// it is excluded by the Controller's IdentityFilter.
+func (c *Controller) record(edge Edge) {
+	if !c.filter.Allows(edge.SourceIdentity) {
+		return
+	}
+
+	destinations, ok := c.pending[edge.SourceIdentity]
+	if !ok {
+		destinations = make(map[string]*dependencyv1alpha1.DependencyDestination)
+		c.pending[edge.SourceIdentity] = destinations
+	}
+
+	key := fmt.Sprintf("%s:%d", edge.DestHost, edge.DestPort)
+	now := metav1.Now()
+	if dest, ok := destinations[key]; ok {
+		dest.LastSeen = now
+		dest.SampleCount++
+		return
+	}
+	destinations[key] = &dependencyv1alpha1.DependencyDestination{
+		Host:        edge.DestHost,
+		Port:        edge.DestPort,
+		FirstSeen:   now,
+		LastSeen:    now,
+		SampleCount: 1,
+	}
+}
+
+// flush upserts every batched source identity's Dependency resource and
+// clears the in-memory batch.
+func (c *Controller) flush(ctx context.Context) error {
+	for sourceIdentity, destinations := range c.pending {
+		if err := c.upsert(ctx, sourceIdentity, destinations); err != nil {
+			return fmt.Errorf("dependency: upserting %q: %w", sourceIdentity, err)
+		}
+	}
+	c.pending = make(map[string]map[string]*dependencyv1alpha1.DependencyDestination)
+	return nil
+}
+
+// upsert merges destinations into the Dependency resource for
+// sourceIdentity, creating it if it does not already exist.
+func (c *Controller) upsert(ctx context.Context, sourceIdentity string, destinations map[string]*dependencyv1alpha1.DependencyDestination) error {
+	name := resourceName(sourceIdentity)
+
+	dep := &dependencyv1alpha1.Dependency{}
+	err := c.client.Get(ctx, types.NamespacedName{Namespace: c.namespace, Name: name}, dep)
+	switch {
+	case apierrors.IsNotFound(err):
+		dep = &dependencyv1alpha1.Dependency{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.namespace,
+			},
+			Spec: dependencyv1alpha1.DependencySpec{
+				SourceIdentity: sourceIdentity,
+				Mode:           c.defaultMode,
+			},
+		}
+		mergeDestinations(&dep.Spec, destinations)
+		if err := c.client.Create(ctx, dep); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		mergeDestinations(&dep.Spec, destinations)
+		if err := c.client.Update(ctx, dep); err != nil {
+			return err
+		}
+	}
+
+	// status is a subresource, so it is only persisted through Status().
+	dep.Status.LastReconciled = metav1.Now()
+	return c.client.Status().Update(ctx, dep)
+}
+
+// mergeDestinations folds batched destinations into spec, updating
+// last-seen and sample counts for destinations already present (their
+// first-seen is kept).
+func mergeDestinations(spec *dependencyv1alpha1.DependencySpec, batched map[string]*dependencyv1alpha1.DependencyDestination) {
+	existing := make(map[string]int, len(spec.Destinations))
+	for i, dest := range spec.Destinations {
+		existing[fmt.Sprintf("%s:%d", dest.Host, dest.Port)] = i
+	}
+
+	for key, dest := range batched {
+		if i, ok := existing[key]; ok {
+			spec.Destinations[i].LastSeen = dest.LastSeen
+			spec.Destinations[i].SampleCount += dest.SampleCount
+			continue
+		}
+		spec.Destinations = append(spec.Destinations, *dest)
+	}
+}
+
+// resourceName derives a valid Kubernetes object name from a SPIFFE ID,
+// which may contain characters (':', '/') that aren't allowed in names.
+func resourceName(sourceIdentity string) string {
+	sum := sha256.Sum256([]byte(sourceIdentity))
+	return "dep-" + hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,50 @@
+// Package dependency aggregates edges emitted by the gateway's
+// dependency-learner WasmPlugin and persists them as Dependency custom
+// resources.
+package dependency
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Edge is a single observed source -> destination call, as reported by the
+// gateway in the `detected-dependency` response header.
+type Edge struct {
+	// SourceIdentity is the SPIFFE ID of the calling workload.
+	SourceIdentity string
+	// DestHost is the upstream cluster's service host.
+	DestHost string
+	// DestPort is the upstream port that was dialed.
+	DestPort uint32
+}
+
+// ParseHeaderValue parses a `detected-dependency` header value of the form
+// "<spiffe-id> -> outbound|<port>||<host>" into an Edge.
+func ParseHeaderValue(value string) (Edge, error) {
+	parts := strings.SplitN(value, " -> ", 2)
+	if len(parts) != 2 {
+		return Edge{}, fmt.Errorf("dependency: malformed header value %q", value)
+	}
+	sourceIdentity := strings.TrimSpace(parts[0])
+
+	clusterParts := strings.Split(strings.TrimSpace(parts[1]), "|")
+	if len(clusterParts) != 4 || clusterParts[0] != "outbound" {
+		return Edge{}, fmt.Errorf("dependency: malformed cluster name %q", parts[1])
+	}
+	port, err := strconv.ParseUint(clusterParts[1], 10, 32)
+	if err != nil {
+		return Edge{}, fmt.Errorf("dependency: malformed port in cluster name %q: %w", parts[1], err)
+	}
+	host := clusterParts[3]
+	if host == "" {
+		return Edge{}, fmt.Errorf("dependency: malformed cluster name %q: missing host", parts[1])
+	}
+
+	return Edge{
+		SourceIdentity: sourceIdentity,
+		DestHost:       host,
+		DestPort:       uint32(port),
+	}, nil
+}
@@ -0,0 +1,38 @@
+package dependency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeaderValue(t *testing.T) {
+	edge, err := ParseHeaderValue("spiffe://cluster.local/ns/foo/sa/default -> outbound|80||server.server-ns.svc.cluster.local")
+	require.NoError(t, err)
+	assert.Equal(t, Edge{
+		SourceIdentity: "spiffe://cluster.local/ns/foo/sa/default",
+		DestHost:       "server.server-ns.svc.cluster.local",
+		DestPort:       80,
+	}, edge)
+}
+
+func TestParseHeaderValueRejectsMissingArrow(t *testing.T) {
+	_, err := ParseHeaderValue("spiffe://cluster.local/ns/foo/sa/default outbound|80||server.server-ns.svc.cluster.local")
+	assert.Error(t, err)
+}
+
+func TestParseHeaderValueRejectsNonOutboundCluster(t *testing.T) {
+	_, err := ParseHeaderValue("spiffe://cluster.local/ns/foo/sa/default -> inbound|80||server.server-ns.svc.cluster.local")
+	assert.Error(t, err)
+}
+
+func TestParseHeaderValueRejectsMalformedPort(t *testing.T) {
+	_, err := ParseHeaderValue("spiffe://cluster.local/ns/foo/sa/default -> outbound|notaport||server.server-ns.svc.cluster.local")
+	assert.Error(t, err)
+}
+
+func TestParseHeaderValueRejectsMissingHost(t *testing.T) {
+	_, err := ParseHeaderValue("spiffe://cluster.local/ns/foo/sa/default -> outbound|80||")
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package dependency
+
+import "path"
+
+// IdentityFilter decides whether a source identity's edges should be
+// captured. It mirrors the excluded-identity concept used elsewhere in mesh
+// tooling to keep infrastructure identities (health checks, telemetry
+// scrapers, etc.) out of the learned dependency graph.
+//
+// Patterns are matched with path.Match against the full SPIFFE ID, so both
+// exact IDs (spiffe://cluster.local/ns/foo/sa/default) and globs
+// (spiffe://cluster.local/ns/foo/sa/*) are supported.
+type IdentityFilter struct {
+	included []string
+	excluded []string
+}
+
+// NewIdentityFilter returns a filter that excludes any source identity
+// matching an excluded pattern, and, when included is non-empty, also
+// requires a match against an included pattern. Exclusion always takes
+// precedence over inclusion.
+func NewIdentityFilter(included, excluded []string) *IdentityFilter {
+	return &IdentityFilter{included: included, excluded: excluded}
+}
+
+// Allows reports whether edges from sourceIdentity should be captured.
+func (f *IdentityFilter) Allows(sourceIdentity string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.excluded {
+		if matchIdentity(pattern, sourceIdentity) {
+			return false
+		}
+	}
+	if len(f.included) == 0 {
+		return true
+	}
+	for _, pattern := range f.included {
+		if matchIdentity(pattern, sourceIdentity) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIdentity(pattern, sourceIdentity string) bool {
+	matched, err := path.Match(pattern, sourceIdentity)
+	return err == nil && matched
+}
@@ -0,0 +1,38 @@
+package dependency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityFilterNilAllowsEverything(t *testing.T) {
+	var f *IdentityFilter
+	assert.True(t, f.Allows("spiffe://cluster.local/ns/foo/sa/default"))
+}
+
+func TestIdentityFilterNoListsAllowsEverything(t *testing.T) {
+	f := NewIdentityFilter(nil, nil)
+	assert.True(t, f.Allows("spiffe://cluster.local/ns/foo/sa/default"))
+}
+
+func TestIdentityFilterExcludedIsDenied(t *testing.T) {
+	f := NewIdentityFilter(nil, []string{"spiffe://cluster.local/ns/infra/sa/*"})
+	assert.False(t, f.Allows("spiffe://cluster.local/ns/infra/sa/healthcheck"))
+	assert.True(t, f.Allows("spiffe://cluster.local/ns/foo/sa/default"))
+}
+
+func TestIdentityFilterIncludedRequiresMatch(t *testing.T) {
+	f := NewIdentityFilter([]string{"spiffe://cluster.local/ns/foo/sa/*"}, nil)
+	assert.True(t, f.Allows("spiffe://cluster.local/ns/foo/sa/default"))
+	assert.False(t, f.Allows("spiffe://cluster.local/ns/bar/sa/default"))
+}
+
+func TestIdentityFilterExclusionTakesPrecedenceOverInclusion(t *testing.T) {
+	f := NewIdentityFilter(
+		[]string{"spiffe://cluster.local/ns/foo/sa/*"},
+		[]string{"spiffe://cluster.local/ns/foo/sa/default"},
+	)
+	assert.False(t, f.Allows("spiffe://cluster.local/ns/foo/sa/default"))
+	assert.True(t, f.Allows("spiffe://cluster.local/ns/foo/sa/other"))
+}
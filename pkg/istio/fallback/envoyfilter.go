@@ -0,0 +1,98 @@
+package fallback
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	apinetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dependencyLearnerLuaTemplate implements the same detected-dependency
+// header logic as the PluginModeWasm backend: on the response path it reads
+// the downstream request's SPIFFE principal off the peer certificate and
+// the upstream cluster Envoy selected (already in Istio's
+// "outbound|<port>||<host>" form, which is exactly the format
+// pkg/dependency.ParseHeaderValue expects after the " -> "), then sets them
+// on the configured response header. The one %s verb is the header name.
+const dependencyLearnerLuaTemplate = `
+function envoy_on_response(response_handle)
+  local ssl = response_handle:streamInfo():downstreamSslConnection()
+  if ssl == nil then
+    return
+  end
+  local sans = ssl:uriSanPeerCertificate()
+  if sans == nil or #sans == 0 then
+    return
+  end
+
+  local upstream = response_handle:streamInfo():upstreamClusterInfo()
+  if upstream == nil then
+    return
+  end
+
+  response_handle:headers():replace("%s", sans[1] .. " -> " .. upstream:name())
+end
+`
+
+// EnvoyFilterParams configures the PluginModeEnvoyFilter detection backend.
+type EnvoyFilterParams struct {
+	Namespace      string
+	Name           string
+	SelectorKey    string
+	SelectorValue  string
+	ResponseHeader string
+}
+
+// BuildEnvoyFilter returns an EnvoyFilter that inserts a Lua HTTP filter
+// implementing the same detection logic as the WasmPlugin backend, for
+// gateways where Wasm or hostPath mounts aren't available.
+func BuildEnvoyFilter(p EnvoyFilterParams) (*apinetworkingv1alpha3.EnvoyFilter, error) {
+	luaFilter, err := structpb.NewStruct(map[string]interface{}{
+		"name": "envoy.filters.http.lua",
+		"typed_config": map[string]interface{}{
+			"@type":      "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+			"inlineCode": fmt.Sprintf(dependencyLearnerLuaTemplate, p.ResponseHeader),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fallback: building lua filter patch: %w", err)
+	}
+
+	return &apinetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: networkingv1alpha3.EnvoyFilter{
+			WorkloadSelector: &networkingv1alpha3.WorkloadSelector{
+				Labels: map[string]string{p.SelectorKey: p.SelectorValue},
+			},
+			ConfigPatches: []*networkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networkingv1alpha3.EnvoyFilter_HTTP_FILTER,
+					Match: &networkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: networkingv1alpha3.EnvoyFilter_GATEWAY,
+						ObjectTypes: &networkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &networkingv1alpha3.EnvoyFilter_ListenerMatch{
+								FilterChain: &networkingv1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &networkingv1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: "envoy.filters.network.http_connection_manager",
+										SubFilter: &networkingv1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+											Name: "envoy.filters.http.router",
+										},
+									},
+								},
+							},
+						},
+					},
+					Patch: &networkingv1alpha3.EnvoyFilter_Patch{
+						Operation: networkingv1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value:     luaFilter,
+					},
+				},
+			},
+		},
+	}, nil
+}
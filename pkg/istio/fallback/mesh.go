@@ -0,0 +1,160 @@
+// Package fallback builds the Istio resources the dependency learner needs
+// when workloads can't be configured to talk to a server directly: a
+// Gateway/VirtualService pair that redirects in-mesh traffic through a
+// gateway running the configured detection backend (see PluginMode), and
+// the ServiceEntry/VirtualService pair each client namespace needs to have
+// its egress routed there. Both detection backends share these resources,
+// so a caller builds them once regardless of which PluginMode it picks.
+package fallback
+
+import (
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	apinetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// meshHosts is the wildcard host set every resource in this package routes
+// for: any in-mesh cluster-local service.
+var meshHosts = []string{"*.svc", "*.svc.cluster.local"}
+
+// GatewayParams configures the mTLS Gateway that terminates traffic
+// redirected through the dependency learner.
+type GatewayParams struct {
+	Namespace     string
+	Name          string
+	SelectorKey   string
+	SelectorValue string
+}
+
+// BuildGateway returns the Gateway that fallback traffic is routed through
+// so the configured detection backend can observe it.
+func BuildGateway(p GatewayParams) *apinetworkingv1alpha3.Gateway {
+	return &apinetworkingv1alpha3.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: networkingv1alpha3.Gateway{
+			Servers: []*networkingv1alpha3.Server{
+				{
+					Name:  p.Name,
+					Hosts: meshHosts,
+					Port: &networkingv1alpha3.Port{
+						Number:   443,
+						Protocol: "https",
+						Name:     "https",
+					},
+					Tls: &networkingv1alpha3.ServerTLSSettings{
+						Mode: networkingv1alpha3.ServerTLSSettings_ISTIO_MUTUAL,
+					},
+				},
+			},
+			Selector: map[string]string{p.SelectorKey: p.SelectorValue},
+		},
+	}
+}
+
+// GatewayRouteParams configures the VirtualService that, once traffic
+// addressed to DestHostPrefix reaches the gateway, forwards it on to
+// DestHost.
+type GatewayRouteParams struct {
+	Namespace      string
+	Name           string
+	GatewayName    string
+	DestHostPrefix string
+	DestHost       string
+}
+
+// BuildGatewayRoute returns the VirtualService bound to the Gateway that
+// forwards traffic matching DestHostPrefix on to DestHost.
+func BuildGatewayRoute(p GatewayRouteParams) *apinetworkingv1alpha3.VirtualService {
+	return &apinetworkingv1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: networkingv1alpha3.VirtualService{
+			Hosts: meshHosts,
+			Http: []*networkingv1alpha3.HTTPRoute{
+				{
+					Match: []*networkingv1alpha3.HTTPMatchRequest{
+						{
+							Authority: &networkingv1alpha3.StringMatch{
+								MatchType: &networkingv1alpha3.StringMatch_Prefix{
+									Prefix: p.DestHostPrefix,
+								},
+							},
+						},
+					},
+					Route: []*networkingv1alpha3.HTTPRouteDestination{
+						{
+							Destination: &networkingv1alpha3.Destination{
+								Host: p.DestHost,
+							},
+						},
+					},
+				},
+			},
+			Gateways: []string{p.GatewayName},
+			ExportTo: []string{"."},
+		},
+	}
+}
+
+// ClientEgressParams configures the ServiceEntry/VirtualService pair that
+// redirects a single namespace's mesh egress through the dependency learner
+// gateway.
+type ClientEgressParams struct {
+	Namespace   string
+	Name        string
+	GatewayHost string
+	GatewayPort uint32
+}
+
+// BuildClientServiceEntry returns the ServiceEntry a namespace needs so its
+// workloads can resolve any cluster-local host without a real DNS/routing
+// path; BuildClientVirtualService supplies the routing itself.
+func BuildClientServiceEntry(p ClientEgressParams) *apinetworkingv1alpha3.ServiceEntry {
+	return &apinetworkingv1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: networkingv1alpha3.ServiceEntry{
+			Hosts:      meshHosts,
+			Resolution: networkingv1alpha3.ServiceEntry_NONE,
+			ExportTo:   []string{p.Namespace},
+		},
+	}
+}
+
+// BuildClientVirtualService returns the VirtualService that redirects a
+// namespace's mesh egress to the dependency learner gateway at
+// GatewayHost:GatewayPort.
+func BuildClientVirtualService(p ClientEgressParams) *apinetworkingv1alpha3.VirtualService {
+	return &apinetworkingv1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: networkingv1alpha3.VirtualService{
+			Hosts: meshHosts,
+			Http: []*networkingv1alpha3.HTTPRoute{
+				{
+					Route: []*networkingv1alpha3.HTTPRouteDestination{
+						{
+							Destination: &networkingv1alpha3.Destination{
+								Host: p.GatewayHost,
+								Port: &networkingv1alpha3.PortSelector{
+									Number: p.GatewayPort,
+								},
+							},
+						},
+					},
+				},
+			},
+			Gateways: []string{"mesh"},
+			ExportTo: []string{"."},
+		},
+	}
+}
@@ -0,0 +1,22 @@
+package fallback
+
+// PluginMode selects which Envoy-level mechanism enforces the dependency
+// learner's detection logic on the gateway.
+type PluginMode string
+
+const (
+	// PluginModeWasm loads the compiled dependency_learner.wasm module via a
+	// WasmPlugin, mounted from a hostPath volume on the gateway. This is the
+	// default: it's the richest backend, supporting configuration like
+	// included_identities/excluded_identities directly at the gateway.
+	PluginModeWasm PluginMode = "wasm"
+
+	// PluginModeEnvoyFilter installs the same response-header detection
+	// logic as an inline Lua envoy.filters.http.lua filter via an
+	// EnvoyFilter, for gateways that disable Wasm or don't allow hostPath
+	// mounts. It does not support identity filtering at the gateway itself;
+	// callers relying on included_identities/excluded_identities under this
+	// mode must depend on pkg/dependency.IdentityFilter's downstream checks
+	// instead.
+	PluginModeEnvoyFilter PluginMode = "envoyfilter"
+)
@@ -0,0 +1,65 @@
+package fallback
+
+import (
+	"fmt"
+	"path"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	extensionsv1alpha1 "istio.io/api/extensions/v1alpha1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	apiextensionsv1alpha1 "istio.io/client-go/pkg/apis/extensions/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WasmPluginParams configures the PluginModeWasm detection backend.
+type WasmPluginParams struct {
+	Namespace          string
+	Name               string
+	SelectorKey        string
+	SelectorValue      string
+	MountPath          string
+	WasmRelativePath   string
+	ResponseHeader     string
+	IncludedIdentities []string
+	ExcludedIdentities []string
+}
+
+// BuildWasmPlugin returns the WasmPlugin that loads the dependency_learner
+// module from a hostPath-mounted volume and configures it with
+// ResponseHeader and the identity allow/deny lists.
+func BuildWasmPlugin(p WasmPluginParams) (*apiextensionsv1alpha1.WasmPlugin, error) {
+	pluginConfig, err := structpb.NewStruct(map[string]interface{}{
+		"response_header":     p.ResponseHeader,
+		"included_identities": toInterfaceSlice(p.IncludedIdentities),
+		"excluded_identities": toInterfaceSlice(p.ExcludedIdentities),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fallback: building wasm plugin config: %w", err)
+	}
+
+	return &apiextensionsv1alpha1.WasmPlugin{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		},
+		Spec: extensionsv1alpha1.WasmPlugin{
+			Selector: &typev1beta1.WorkloadSelector{
+				MatchLabels: map[string]string{p.SelectorKey: p.SelectorValue},
+			},
+			Url:          "file://" + path.Join(p.MountPath, p.WasmRelativePath),
+			Type:         extensionsv1alpha1.PluginType_HTTP,
+			Phase:        extensionsv1alpha1.PluginPhase_UNSPECIFIED_PHASE,
+			PluginConfig: pluginConfig,
+		},
+	}, nil
+}
+
+// toInterfaceSlice converts ss to []interface{} so it can be stored in a
+// structpb.Struct, which only accepts that shape for list values.
+func toInterfaceSlice(ss []string) []interface{} {
+	out := make([]interface{}, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
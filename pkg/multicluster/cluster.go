@@ -0,0 +1,43 @@
+// Package multicluster discovers remote Istio clusters from kubeconfig
+// secrets and keeps a live client for each, following the pattern used by
+// Admiral's secret controller: a Secret labeled to opt in to cluster
+// discovery, containing a kubeconfig, drives add/update/delete of a remote
+// cluster client.
+package multicluster
+
+import (
+	"context"
+
+	"k8s.io/client-go/rest"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Cluster is a single remote cluster discovered via a kubeconfig secret.
+type Cluster struct {
+	// Name identifies the cluster. It is derived from the secret's data
+	// key (the kubeconfig file name), matching Admiral's convention.
+	Name string
+
+	// Config is the REST config parsed from the cluster's kubeconfig.
+	Config *rest.Config
+
+	// Client is a controller-runtime client constructed from Config.
+	Client ctrlclient.Client
+}
+
+// ClusterHandler reacts to clusters being discovered, updated, or removed.
+// Implementations are responsible for any cluster-scoped bootstrap (e.g.
+// installing the fallback Gateway/VirtualService/WasmPlugin) and for
+// subscribing to that cluster's edges.
+type ClusterHandler interface {
+	// OnAdd is called the first time a cluster's secret is observed.
+	OnAdd(ctx context.Context, cluster Cluster) error
+
+	// OnUpdate is called when a previously known cluster's kubeconfig
+	// changes, e.g. during credential rotation. Implementations should
+	// replace any cached client/config rather than requiring a restart.
+	OnUpdate(ctx context.Context, cluster Cluster) error
+
+	// OnDelete is called when a cluster's secret is removed.
+	OnDelete(ctx context.Context, clusterName string) error
+}
@@ -0,0 +1,73 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/wtzhang23/l7router/pkg/dependency"
+)
+
+// BootstrapFunc installs the per-cluster resources (fallback Gateway /
+// VirtualService / WasmPlugin) needed before a cluster's edges can be
+// observed. It is supplied by the caller so this package stays agnostic of
+// how those resources are built (see pkg/istio/fallback).
+type BootstrapFunc func(ctx context.Context, cluster Cluster) error
+
+// DependencyHandler is a ClusterHandler that bootstraps every discovered
+// cluster and feeds its edges into a single shared channel, so a single
+// dependency.Controller can upsert one canonical Dependency CR per SPIFFE
+// identity regardless of which cluster it was observed in.
+type DependencyHandler struct {
+	Bootstrap BootstrapFunc
+	edges     chan<- dependency.Edge
+
+	mu         sync.Mutex
+	aggregator *dependency.Aggregator
+}
+
+// NewDependencyHandler returns a DependencyHandler that publishes every
+// cluster's edges onto edges. filter is applied identically across every
+// cluster, since exclusion lists (e.g. for infrastructure identities) are
+// global rather than per-cluster.
+func NewDependencyHandler(edges chan<- dependency.Edge, bootstrap BootstrapFunc, filter *dependency.IdentityFilter) *DependencyHandler {
+	return &DependencyHandler{
+		Bootstrap:  bootstrap,
+		edges:      edges,
+		aggregator: dependency.NewAggregator(edges, filter),
+	}
+}
+
+// Aggregator returns the shared Aggregator that every cluster's edges are
+// forwarded through. All clusters share one instance because merging happens
+// downstream, keyed by SPIFFE identity, not by cluster.
+func (h *DependencyHandler) Aggregator() *dependency.Aggregator {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.aggregator
+}
+
+// OnAdd bootstraps cluster so its edges start flowing into the shared
+// aggregator.
+func (h *DependencyHandler) OnAdd(ctx context.Context, cluster Cluster) error {
+	if h.Bootstrap == nil {
+		return nil
+	}
+	if err := h.Bootstrap(ctx, cluster); err != nil {
+		return fmt.Errorf("multicluster: bootstrapping cluster %q: %w", cluster.Name, err)
+	}
+	return nil
+}
+
+// OnUpdate re-runs bootstrap against the cluster's refreshed client, so
+// credential rotation doesn't require restarting the process.
+func (h *DependencyHandler) OnUpdate(ctx context.Context, cluster Cluster) error {
+	return h.OnAdd(ctx, cluster)
+}
+
+// OnDelete is a no-op: edges already in flight from a removed cluster are
+// harmless, and no per-cluster state needs tearing down since the
+// aggregator is shared.
+func (h *DependencyHandler) OnDelete(ctx context.Context, clusterName string) error {
+	return nil
+}
@@ -0,0 +1,185 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/clientcmd"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterSecretLabel marks a Secret in the install namespace as containing
+// one or more remote cluster kubeconfigs. Every key in the secret's Data is
+// treated as a distinct cluster, named after the key, matching Admiral's
+// convention.
+const ClusterSecretLabel = "l7router/cluster"
+
+// resyncPeriod is passed to the shared informer factory; re-lists guard
+// against missed watch events rather than driving normal reconciliation.
+const resyncPeriod = 10 * time.Minute
+
+// SecretController watches Secrets labeled ClusterSecretLabel=true in a
+// single namespace and notifies a ClusterHandler as remote clusters are
+// discovered, rotated (secret UPDATE), or removed.
+type SecretController struct {
+	namespace string
+	scheme    *runtime.Scheme
+	handler   ClusterHandler
+
+	mu sync.Mutex
+	// clusters tracks the kubeconfig bytes last seen for each known
+	// cluster name, keyed by the name of the Secret it was sourced from, so
+	// a diff against one Secret's new contents never considers (and so
+	// never deletes) clusters sourced from a different Secret in the same
+	// namespace.
+	clusters map[string]map[string][]byte
+
+	informer cache.SharedIndexInformer
+}
+
+// NewSecretController returns a SecretController that discovers clusters
+// from Secrets in namespace via kubeClient, dispatching to handler.
+func NewSecretController(kubeClient kubernetes.Interface, namespace string, scheme *runtime.Scheme, handler ClusterHandler) *SecretController {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		kubeClient, resyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = fmt.Sprintf("%s=true", ClusterSecretLabel)
+		}),
+	)
+
+	sc := &SecretController{
+		namespace: namespace,
+		scheme:    scheme,
+		handler:   handler,
+		clusters:  make(map[string]map[string][]byte),
+		informer:  factory.Core().V1().Secrets().Informer(),
+	}
+	return sc
+}
+
+// Start registers event handlers and runs the underlying informer until ctx
+// is cancelled.
+func (sc *SecretController) Start(ctx context.Context) error {
+	_, err := sc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sc.handleSecret(ctx, obj.(*corev1.Secret))
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			sc.handleSecret(ctx, newObj.(*corev1.Secret))
+		},
+		DeleteFunc: func(obj interface{}) {
+			secret, ok := obj.(*corev1.Secret)
+			if !ok {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					secret, _ = tombstone.Obj.(*corev1.Secret)
+				}
+			}
+			if secret != nil {
+				sc.handleSecretDelete(ctx, secret)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("multicluster: registering secret event handler: %w", err)
+	}
+
+	sc.informer.Run(ctx.Done())
+	return nil
+}
+
+// handleSecret diffs secret's kubeconfig keys against the last known state
+// for that same Secret (by secret.Name), issuing OnAdd/OnUpdate for new or
+// changed clusters and OnDelete for clusters whose key was removed from the
+// secret. Clusters sourced from other Secrets in the namespace are never
+// considered, so registering or rotating one Secret can't spuriously tear
+// down clusters owned by another.
+func (sc *SecretController) handleSecret(ctx context.Context, secret *corev1.Secret) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	known := sc.clusters[secret.Name]
+
+	seen := make(map[string]struct{}, len(secret.Data))
+	updated := make(map[string][]byte, len(secret.Data))
+	for name, kubeconfig := range secret.Data {
+		seen[name] = struct{}{}
+
+		prev, wasKnown := known[name]
+		if wasKnown && string(prev) == string(kubeconfig) {
+			updated[name] = prev
+			continue
+		}
+
+		cluster, err := sc.buildCluster(name, kubeconfig)
+		if err != nil {
+			// Keep the previous entry (if any); a malformed update should
+			// not tear down a cluster that was working.
+			if wasKnown {
+				updated[name] = prev
+			}
+			continue
+		}
+
+		updated[name] = kubeconfig
+		if wasKnown {
+			_ = sc.handler.OnUpdate(ctx, cluster)
+		} else {
+			_ = sc.handler.OnAdd(ctx, cluster)
+		}
+	}
+
+	for name := range known {
+		if _, ok := seen[name]; !ok {
+			_ = sc.handler.OnDelete(ctx, name)
+		}
+	}
+
+	if len(updated) == 0 {
+		delete(sc.clusters, secret.Name)
+	} else {
+		sc.clusters[secret.Name] = updated
+	}
+}
+
+// handleSecretDelete removes every cluster that was sourced from secret.
+func (sc *SecretController) handleSecretDelete(ctx context.Context, secret *corev1.Secret) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	known := sc.clusters[secret.Name]
+	delete(sc.clusters, secret.Name)
+	for name := range known {
+		_ = sc.handler.OnDelete(ctx, name)
+	}
+}
+
+// buildCluster parses kubeconfig and constructs a controller-runtime client
+// for the resulting REST config.
+func (sc *SecretController) buildCluster(name string, kubeconfig []byte) (Cluster, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return Cluster{}, fmt.Errorf("multicluster: parsing kubeconfig for cluster %q: %w", name, err)
+	}
+
+	c, err := newClusterClient(config, sc.scheme)
+	if err != nil {
+		return Cluster{}, fmt.Errorf("multicluster: building client for cluster %q: %w", name, err)
+	}
+
+	return Cluster{Name: name, Config: config, Client: c}, nil
+}
+
+// newClusterClient is a seam for tests to stub out client construction.
+var newClusterClient = func(config *rest.Config, scheme *runtime.Scheme) (ctrlclient.Client, error) {
+	return ctrlclient.New(config, ctrlclient.Options{Scheme: scheme})
+}
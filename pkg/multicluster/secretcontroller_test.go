@@ -0,0 +1,150 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validKubeconfig is a minimal kubeconfig that clientcmd can parse without
+// dialing anything, so buildCluster succeeds without a live apiserver.
+const validKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://example.invalid
+  name: c
+contexts:
+- context:
+    cluster: c
+    user: u
+  name: ctx
+current-context: ctx
+users:
+- name: u
+  user:
+    token: t
+`
+
+// recordingHandler is a ClusterHandler that records every call it receives,
+// for asserting exactly which clusters a SecretController diff touched.
+type recordingHandler struct {
+	added   []string
+	updated []string
+	deleted []string
+}
+
+func (h *recordingHandler) OnAdd(_ context.Context, cluster Cluster) error {
+	h.added = append(h.added, cluster.Name)
+	return nil
+}
+
+func (h *recordingHandler) OnUpdate(_ context.Context, cluster Cluster) error {
+	h.updated = append(h.updated, cluster.Name)
+	return nil
+}
+
+func (h *recordingHandler) OnDelete(_ context.Context, clusterName string) error {
+	h.deleted = append(h.deleted, clusterName)
+	return nil
+}
+
+func newTestSecretController(handler ClusterHandler) *SecretController {
+	return &SecretController{
+		namespace: "istio-system",
+		handler:   handler,
+		clusters:  make(map[string]map[string][]byte),
+	}
+}
+
+func secret(name string, data map[string]string) *corev1.Secret {
+	bytesData := make(map[string][]byte, len(data))
+	for k, v := range data {
+		bytesData[k] = []byte(v)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data:       bytesData,
+	}
+}
+
+func TestSecretControllerHandleSecretAddsNewClusters(t *testing.T) {
+	handler := &recordingHandler{}
+	sc := newTestSecretController(handler)
+
+	sc.handleSecret(context.Background(), secret("cluster-secret-1", map[string]string{
+		"west": validKubeconfig,
+	}))
+
+	assert.ElementsMatch(t, []string{"west"}, handler.added)
+	assert.Empty(t, handler.updated)
+	assert.Empty(t, handler.deleted)
+}
+
+func TestSecretControllerHandleSecretScopesDiffToOwnSecret(t *testing.T) {
+	handler := &recordingHandler{}
+	sc := newTestSecretController(handler)
+
+	sc.handleSecret(context.Background(), secret("cluster-secret-1", map[string]string{
+		"west": validKubeconfig,
+	}))
+	sc.handleSecret(context.Background(), secret("cluster-secret-2", map[string]string{
+		"east": validKubeconfig,
+	}))
+	require.ElementsMatch(t, []string{"west", "east"}, handler.added)
+
+	// re-syncing cluster-secret-2 with the same contents must not touch
+	// cluster-secret-1's "west" cluster at all.
+	handler.added = nil
+	sc.handleSecret(context.Background(), secret("cluster-secret-2", map[string]string{
+		"east": validKubeconfig,
+	}))
+	assert.Empty(t, handler.added)
+	assert.Empty(t, handler.updated)
+	assert.Empty(t, handler.deleted)
+}
+
+func TestSecretControllerHandleSecretDeletesOnlyOwnRemovedKeys(t *testing.T) {
+	handler := &recordingHandler{}
+	sc := newTestSecretController(handler)
+
+	sc.handleSecret(context.Background(), secret("cluster-secret-1", map[string]string{
+		"west": validKubeconfig,
+	}))
+	sc.handleSecret(context.Background(), secret("cluster-secret-2", map[string]string{
+		"east": validKubeconfig,
+	}))
+
+	// cluster-secret-2 drops its only key; "west", sourced from a different
+	// secret, must not be deleted as a side effect.
+	sc.handleSecret(context.Background(), secret("cluster-secret-2", map[string]string{}))
+
+	assert.ElementsMatch(t, []string{"east"}, handler.deleted)
+	assert.Contains(t, sc.clusters, "cluster-secret-1")
+	assert.NotContains(t, sc.clusters, "cluster-secret-2")
+}
+
+func TestSecretControllerHandleSecretDeleteRemovesOnlyThatSecretsClusters(t *testing.T) {
+	handler := &recordingHandler{}
+	sc := newTestSecretController(handler)
+
+	sc.handleSecret(context.Background(), secret("cluster-secret-1", map[string]string{
+		"west": validKubeconfig,
+	}))
+	sc.handleSecret(context.Background(), secret("cluster-secret-2", map[string]string{
+		"east": validKubeconfig,
+	}))
+
+	sc.handleSecretDelete(context.Background(), secret("cluster-secret-1", map[string]string{
+		"west": validKubeconfig,
+	}))
+
+	assert.ElementsMatch(t, []string{"west"}, handler.deleted)
+	assert.NotContains(t, sc.clusters, "cluster-secret-1")
+	assert.Contains(t, sc.clusters, "cluster-secret-2")
+}
@@ -0,0 +1,278 @@
+// Package policygen reconciles learned Dependency resources into Istio
+// AuthorizationPolicies that whitelist only the edges a Dependency has
+// actually observed.
+package policygen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	securityv1beta1 "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	apisecurityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dependencyv1alpha1 "github.com/wtzhang23/l7router/pkg/apis/dependency/v1alpha1"
+)
+
+// DryRunAnnotation marks an AuthorizationPolicy generated from a Dependency
+// in dependencyv1alpha1.ModeDryRun, so an operator can tell an audit-only
+// policy apart from an enforced one at a glance.
+const DryRunAnnotation = "l7router/dryrun"
+
+// DependencyLabel records the name of the Dependency an AuthorizationPolicy
+// was generated from, so reconcileOne can list everything it previously
+// created for a Dependency and delete whatever is no longer desired.
+const DependencyLabel = "l7router/dependency"
+
+// DefaultPollInterval is how often the Generator re-lists Dependency
+// resources and reconciles their AuthorizationPolicies.
+const DefaultPollInterval = 10 * time.Second
+
+// Generator reconciles Dependency resources in dependencyv1alpha1.ModeDryRun
+// or ModeEnforce into per-destination-namespace AuthorizationPolicies that
+// allow only the observed source identity -> destination edges. Once any
+// ALLOW AuthorizationPolicy selects a workload, Istio denies all traffic to
+// it that doesn't match an ALLOW rule, so no separate default-deny policy is
+// generated for ModeEnforce.
+type Generator struct {
+	depClient    client.Client
+	policyClient client.Client
+	namespace    string
+	interval     time.Duration
+}
+
+// NewGenerator returns a Generator that lists Dependency resources from
+// namespace via depClient and upserts AuthorizationPolicies via policyClient,
+// polling every interval. depClient and policyClient are separate because
+// the canonical Dependency resources live on a hub cluster (see
+// pkg/multicluster) while the AuthorizationPolicies they produce must be
+// applied to the mesh cluster that actually runs the destination workloads;
+// a single-cluster caller may pass the same client for both.
+func NewGenerator(depClient, policyClient client.Client, namespace string, interval time.Duration) *Generator {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &Generator{
+		depClient:    depClient,
+		policyClient: policyClient,
+		namespace:    namespace,
+		interval:     interval,
+	}
+}
+
+// Run reconciles every Dependency in Generator.namespace immediately, then
+// again every Generator.interval, until ctx is cancelled.
+func (g *Generator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	if err := g.reconcileAll(ctx); err != nil {
+		return fmt.Errorf("policygen: reconciling: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := g.reconcileAll(ctx); err != nil {
+				return fmt.Errorf("policygen: reconciling: %w", err)
+			}
+		}
+	}
+}
+
+// reconcileAll lists every Dependency in Generator.namespace and reconciles
+// its AuthorizationPolicies in turn.
+func (g *Generator) reconcileAll(ctx context.Context) error {
+	deps := &dependencyv1alpha1.DependencyList{}
+	if err := g.depClient.List(ctx, deps, client.InNamespace(g.namespace)); err != nil {
+		return fmt.Errorf("listing dependencies: %w", err)
+	}
+	for i := range deps.Items {
+		if err := g.reconcileOne(ctx, &deps.Items[i]); err != nil {
+			return fmt.Errorf("dependency %q: %w", deps.Items[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcileOne upserts one AuthorizationPolicy per destination workload dep
+// currently has observed edges to, then deletes any AuthorizationPolicy
+// previously generated from dep that is no longer desired. A Dependency in
+// ModeObserve (or with no mode set) desires no policies at all, so leaving
+// ModeDryRun/ModeEnforce retracts every policy it had generated; likewise a
+// destination that drops out of dep.Spec.Destinations has its policy
+// retracted even while dep stays in ModeDryRun/ModeEnforce. Without this,
+// Istio's implicit deny-by-ALLOW-policy-existence semantics would leave a
+// stale policy enforcing access rules that are no longer accurate.
+func (g *Generator) reconcileOne(ctx context.Context, dep *dependencyv1alpha1.Dependency) error {
+	desired := make(map[string]*apisecurityv1beta1.AuthorizationPolicy)
+	switch dep.Spec.Mode {
+	case dependencyv1alpha1.ModeDryRun, dependencyv1alpha1.ModeEnforce:
+		for _, group := range groupDestinations(dep.Spec.Destinations) {
+			policy := buildAuthorizationPolicy(dep, group)
+			desired[policyKey(policy.Namespace, policy.Name)] = policy
+		}
+	}
+
+	for key, policy := range desired {
+		if err := g.upsert(ctx, policy); err != nil {
+			return fmt.Errorf("upserting authorization policy %q: %w", key, err)
+		}
+	}
+
+	existing := &apisecurityv1beta1.AuthorizationPolicyList{}
+	if err := g.policyClient.List(ctx, existing, client.MatchingLabels{DependencyLabel: dep.Name}); err != nil {
+		return fmt.Errorf("listing authorization policies for dependency %q: %w", dep.Name, err)
+	}
+	for i := range existing.Items {
+		policy := &existing.Items[i]
+		key := policyKey(policy.Namespace, policy.Name)
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if err := g.policyClient.Delete(ctx, policy); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting orphaned authorization policy %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// policyKey uniquely identifies an AuthorizationPolicy within the set
+// reconcileOne manages for a single Dependency.
+func policyKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// upsert creates policy if it doesn't already exist, or overwrites an
+// existing one's labels, annotations, and spec in place.
+func (g *Generator) upsert(ctx context.Context, policy *apisecurityv1beta1.AuthorizationPolicy) error {
+	existing := &apisecurityv1beta1.AuthorizationPolicy{}
+	err := g.policyClient.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		return g.policyClient.Create(ctx, policy)
+	case err != nil:
+		return err
+	default:
+		existing.Labels = policy.Labels
+		existing.Annotations = policy.Annotations
+		existing.Spec = policy.Spec
+		return g.policyClient.Update(ctx, existing)
+	}
+}
+
+// destinationGroup is the observed edges from one Dependency's source
+// identity to a single destination workload, keyed by namespace/service so
+// an AuthorizationPolicy can select that workload precisely.
+type destinationGroup struct {
+	namespace string
+	service   string
+	ports     map[uint32]struct{}
+}
+
+// groupDestinations buckets destinations by the destination workload they
+// target, inferring the service name and namespace from the cluster-local
+// host, e.g. "server.server-ns.svc.cluster.local" groups into service
+// "server" in namespace "server-ns".
+func groupDestinations(destinations []dependencyv1alpha1.DependencyDestination) map[string]*destinationGroup {
+	groups := make(map[string]*destinationGroup)
+	for _, dest := range destinations {
+		service, namespace, ok := splitServiceHost(dest.Host)
+		if !ok {
+			continue
+		}
+		key := namespace + "/" + service
+		group, ok := groups[key]
+		if !ok {
+			group = &destinationGroup{namespace: namespace, service: service, ports: make(map[uint32]struct{})}
+			groups[key] = group
+		}
+		group.ports[dest.Port] = struct{}{}
+	}
+	return groups
+}
+
+// splitServiceHost extracts the service name and namespace from a
+// cluster-local host, e.g. "server.server-ns.svc.cluster.local" returns
+// ("server", "server-ns", true). Hosts that aren't dotted at least twice
+// (headless external hosts discovered via a ServiceEntry, for example)
+// aren't resolvable to a workload selector and are skipped.
+func splitServiceHost(host string) (service, namespace string, ok bool) {
+	parts := strings.SplitN(host, ".", 3)
+	if len(parts) < 3 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// buildAuthorizationPolicy constructs the AuthorizationPolicy for dep's
+// source identity reaching group's destination workload.
+func buildAuthorizationPolicy(dep *dependencyv1alpha1.Dependency, group *destinationGroup) *apisecurityv1beta1.AuthorizationPolicy {
+	action := securityv1beta1.AuthorizationPolicy_ALLOW
+	var annotations map[string]string
+	if dep.Spec.Mode == dependencyv1alpha1.ModeDryRun {
+		action = securityv1beta1.AuthorizationPolicy_AUDIT
+		annotations = map[string]string{DryRunAnnotation: "true"}
+	}
+
+	return &apisecurityv1beta1.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%s", dep.Name, group.service),
+			Namespace:   group.namespace,
+			Labels:      map[string]string{DependencyLabel: dep.Name},
+			Annotations: annotations,
+		},
+		Spec: securityv1beta1.AuthorizationPolicy{
+			Selector: &typev1beta1.WorkloadSelector{
+				MatchLabels: map[string]string{"app": group.service},
+			},
+			Action: action,
+			Rules: []*securityv1beta1.Rule{
+				{
+					From: []*securityv1beta1.Rule_From{
+						{
+							Source: &securityv1beta1.Source{
+								Principals: []string{principalFromSPIFFE(dep.Spec.SourceIdentity)},
+							},
+						},
+					},
+					To: []*securityv1beta1.Rule_To{
+						{
+							Operation: &securityv1beta1.Operation{
+								Ports: sortedPorts(group.ports),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// principalFromSPIFFE converts a SPIFFE URI, e.g.
+// "spiffe://cluster.local/ns/foo/sa/default", into the trust-domain-qualified
+// principal format AuthorizationPolicy rules expect, e.g.
+// "cluster.local/ns/foo/sa/default".
+func principalFromSPIFFE(id string) string {
+	return strings.TrimPrefix(id, "spiffe://")
+}
+
+// sortedPorts renders ports as a sorted string slice for a stable,
+// diff-friendly Operation.Ports.
+func sortedPorts(ports map[uint32]struct{}) []string {
+	out := make([]string, 0, len(ports))
+	for port := range ports {
+		out = append(out, strconv.FormatUint(uint64(port), 10))
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,107 @@
+package policygen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	securityv1beta1 "istio.io/api/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dependencyv1alpha1 "github.com/wtzhang23/l7router/pkg/apis/dependency/v1alpha1"
+)
+
+func TestSplitServiceHost(t *testing.T) {
+	service, namespace, ok := splitServiceHost("server.server-ns.svc.cluster.local")
+	assert.True(t, ok)
+	assert.Equal(t, "server", service)
+	assert.Equal(t, "server-ns", namespace)
+}
+
+func TestSplitServiceHostRejectsUndottedHost(t *testing.T) {
+	_, _, ok := splitServiceHost("server")
+	assert.False(t, ok)
+}
+
+func TestGroupDestinationsBucketsByWorkloadAndMergesPorts(t *testing.T) {
+	groups := groupDestinations([]dependencyv1alpha1.DependencyDestination{
+		{Host: "server.server-ns.svc.cluster.local", Port: 80},
+		{Host: "server.server-ns.svc.cluster.local", Port: 443},
+		{Host: "other.other-ns.svc.cluster.local", Port: 80},
+		// unresolvable hosts are skipped rather than grouped
+		{Host: "headless-external", Port: 80},
+	})
+
+	assert.Len(t, groups, 2)
+
+	server := groups["server-ns/server"]
+	if assert.NotNil(t, server) {
+		assert.Equal(t, "server", server.service)
+		assert.Equal(t, "server-ns", server.namespace)
+		assert.Contains(t, server.ports, uint32(80))
+		assert.Contains(t, server.ports, uint32(443))
+	}
+
+	other := groups["other-ns/other"]
+	if assert.NotNil(t, other) {
+		assert.Contains(t, other.ports, uint32(80))
+	}
+}
+
+func TestBuildAuthorizationPolicyEnforceModeAllows(t *testing.T) {
+	dep := &dependencyv1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-dep"},
+		Spec: dependencyv1alpha1.DependencySpec{
+			SourceIdentity: "spiffe://cluster.local/ns/foo/sa/default",
+			Mode:           dependencyv1alpha1.ModeEnforce,
+		},
+	}
+	group := &destinationGroup{
+		namespace: "server-ns",
+		service:   "server",
+		ports:     map[uint32]struct{}{80: {}},
+	}
+
+	policy := buildAuthorizationPolicy(dep, group)
+
+	assert.Equal(t, "foo-dep-server", policy.Name)
+	assert.Equal(t, "server-ns", policy.Namespace)
+	assert.Equal(t, "foo-dep", policy.Labels[DependencyLabel])
+	assert.Empty(t, policy.Annotations[DryRunAnnotation])
+	assert.Equal(t, securityv1beta1.AuthorizationPolicy_ALLOW, policy.Spec.Action)
+	assert.Equal(t,
+		[]string{"cluster.local/ns/foo/sa/default"},
+		policy.Spec.Rules[0].From[0].Source.Principals,
+	)
+}
+
+func TestBuildAuthorizationPolicyDryRunModeAudits(t *testing.T) {
+	dep := &dependencyv1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-dep"},
+		Spec: dependencyv1alpha1.DependencySpec{
+			SourceIdentity: "spiffe://cluster.local/ns/foo/sa/default",
+			Mode:           dependencyv1alpha1.ModeDryRun,
+		},
+	}
+	group := &destinationGroup{
+		namespace: "server-ns",
+		service:   "server",
+		ports:     map[uint32]struct{}{80: {}},
+	}
+
+	policy := buildAuthorizationPolicy(dep, group)
+
+	assert.Equal(t, securityv1beta1.AuthorizationPolicy_AUDIT, policy.Spec.Action)
+	assert.Equal(t, "true", policy.Annotations[DryRunAnnotation])
+}
+
+func TestSortedPorts(t *testing.T) {
+	ports := map[uint32]struct{}{443: {}, 80: {}, 8080: {}}
+	assert.Equal(t, []string{"443", "80", "8080"}, sortedPorts(ports))
+}
+
+func TestPrincipalFromSPIFFE(t *testing.T) {
+	assert.Equal(t,
+		"cluster.local/ns/foo/sa/default",
+		principalFromSPIFFE("spiffe://cluster.local/ns/foo/sa/default"),
+	)
+}
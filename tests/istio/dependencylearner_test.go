@@ -4,38 +4,72 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"path"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	"google.golang.org/protobuf/types/known/structpb"
-	extensionsv1alpha1 "istio.io/api/extensions/v1alpha1"
 	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
-	typev1beta1 "istio.io/api/type/v1beta1"
+	securityv1beta1 "istio.io/api/security/v1beta1"
 	apiextensionsv1alpha1 "istio.io/client-go/pkg/apis/extensions/v1alpha1"
 	apinetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	apisecurityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
 	istioscheme "istio.io/client-go/pkg/clientset/versioned/scheme"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
 	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	dependencyv1alpha1 "github.com/wtzhang23/l7router/pkg/apis/dependency/v1alpha1"
+	"github.com/wtzhang23/l7router/pkg/dependency"
+	"github.com/wtzhang23/l7router/pkg/istio/fallback"
+	"github.com/wtzhang23/l7router/pkg/multicluster"
+	"github.com/wtzhang23/l7router/pkg/policygen"
 )
 
 const dependencyLearnerComponentLabelValue = "dependency-learner"
 
+type dependencyTestCtxKey string
+
+const (
+	dependencyAggregatorCtxKey dependencyTestCtxKey = "dependency-aggregator"
+	dependencyCancelCtxKey     dependencyTestCtxKey = "dependency-cancel"
+)
+
+// mustRESTConfig parses kubeconfig bytes into a REST config, panicking on
+// failure. It is only used against the hub cluster's own kubeconfig, which
+// TestMain already validated by building a client from it.
+func mustRESTConfig(kubeconfig []byte) *rest.Config {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		panic(fmt.Errorf("dependencylearner_test: parsing hub kubeconfig: %w", err))
+	}
+	return config
+}
+
 func TestDependencyLearner(t *testing.T) {
 	clientNamespace := envconf.RandomName("client", 16)
+	excludedClientNamespace := envconf.RandomName("excluded-client", 16)
+	deniedClientNamespace := envconf.RandomName("denied-client", 16)
 	serverNamespace := envconf.RandomName("server", 16)
 	clientName := "client"
+	excludedClientName := "excluded-client"
+	deniedClientName := "denied-client"
 	serverName := "server"
 	containerName := "testapp"
 	fallbackName := envconf.RandomName("fallback", 16)
 	responseHeader := "detected-dependency"
+	excludedIdentity := fmt.Sprintf("spiffe://cluster.local/ns/%s/sa/default", excludedClientNamespace)
 	determineDependency := features.New("determine dependency").
 		WithLabel("component", dependencyLearnerComponentLabelValue).
 		Setup(
@@ -75,182 +109,217 @@ func TestDependencyLearner(t *testing.T) {
 					return ctx
 				}
 
-				// setup gateway
-				fallbackGatewayObj := &apinetworkingv1alpha3.Gateway{
+				// create excluded client namespace, whose identity is
+				// configured out of dependency capture below
+				excludedClientNamespaceObj := &corev1.Namespace{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: istioNamespace,
-						Name:      fallbackName,
-					},
-					Spec: networkingv1alpha3.Gateway{
-						Servers: []*networkingv1alpha3.Server{
-							{
-								Name: fallbackName,
-								Hosts: []string{
-									"*.svc",
-									"*.svc.cluster.local",
-								},
-								Port: &networkingv1alpha3.Port{
-									Number:   443,
-									Protocol: "https",
-									Name:     "https",
-								},
-								Tls: &networkingv1alpha3.ServerTLSSettings{
-									Mode: networkingv1alpha3.ServerTLSSettings_ISTIO_MUTUAL,
-								},
-							},
-						},
-						Selector: map[string]string{
-							gatewaySelectorKey: gatewaySelectorValue,
+						Name: excludedClientNamespace,
+						Labels: map[string]string{
+							"istio-injection": "enabled",
 						},
 					},
 				}
-				if err := r.Create(ctx, fallbackGatewayObj); !assert.NoError(t, err) {
+				if err := r.Create(ctx, excludedClientNamespaceObj); !assert.NoError(t, err) {
 					return ctx
 				}
 
-				// setup virtual service redirect
-				fallbackVsObj := &apinetworkingv1alpha3.VirtualService{
+				// create denied client namespace. unlike clientNamespace, no
+				// edge is ever reported for this identity, so once the
+				// policygen-generated AuthorizationPolicy is in effect it
+				// should be denied
+				deniedClientNamespaceObj := &corev1.Namespace{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: istioNamespace,
-						Name:      fallbackName,
-					},
-					Spec: networkingv1alpha3.VirtualService{
-						Hosts: []string{
-							"*.svc",
-							"*.svc.cluster.local",
-						},
-						Http: []*networkingv1alpha3.HTTPRoute{
-							{
-								Match: []*networkingv1alpha3.HTTPMatchRequest{
-									{
-										Authority: &networkingv1alpha3.StringMatch{
-											MatchType: &networkingv1alpha3.StringMatch_Prefix{
-												Prefix: fmt.Sprintf("%s.%s.svc", serverName, serverNamespace),
-											},
-										},
-									},
-								},
-								Route: []*networkingv1alpha3.HTTPRouteDestination{
-									{
-										Destination: &networkingv1alpha3.Destination{
-											Host: fmt.Sprintf("%s.%s.svc.cluster.local", serverName, serverNamespace),
-										},
-									},
-								},
-							},
-						},
-						Gateways: []string{
-							fallbackName,
+						Name: deniedClientNamespace,
+						Labels: map[string]string{
+							"istio-injection": "enabled",
 						},
-						ExportTo: []string{"."},
 					},
 				}
-				if err := r.Create(ctx, fallbackVsObj); !assert.NoError(t, err) {
+				if err := r.Create(ctx, deniedClientNamespaceObj); !assert.NoError(t, err) {
 					return ctx
 				}
 
-				// deploy wasm plugin
-				pluginConfig, err := structpb.NewStruct(map[string]interface{}{
-					"response_header": responseHeader,
+				// setup gateway
+				fallbackGatewayObj := fallback.BuildGateway(fallback.GatewayParams{
+					Namespace:     istioNamespace,
+					Name:          fallbackName,
+					SelectorKey:   gatewaySelectorKey,
+					SelectorValue: gatewaySelectorValue,
 				})
-				if !assert.NoError(t, err) {
+				if err := r.Create(ctx, fallbackGatewayObj); !assert.NoError(t, err) {
 					return ctx
 				}
-				fallbackWasmObj := &apiextensionsv1alpha1.WasmPlugin{
-					ObjectMeta: metav1.ObjectMeta{
-						Namespace: istioNamespace,
+
+				// setup virtual service redirect
+				fallbackVsObj := fallback.BuildGatewayRoute(fallback.GatewayRouteParams{
+					Namespace:      istioNamespace,
+					Name:           fallbackName,
+					GatewayName:    fallbackName,
+					DestHostPrefix: fmt.Sprintf("%s.%s.svc", serverName, serverNamespace),
+					DestHost:       fmt.Sprintf("%s.%s.svc.cluster.local", serverName, serverNamespace),
+				})
+				if err := r.Create(ctx, fallbackVsObj); !assert.NoError(t, err) {
+					return ctx
+				}
+
+				// deploy the detection backend selected by pluginMode.
+				// excluded_identities keeps noisy or infrastructure
+				// identities (here, the excluded client) out of dependency
+				// capture; included_identities, when non-empty, would
+				// instead act as an allowlist. PluginModeEnvoyFilter doesn't
+				// support identity filtering at the gateway itself, so the
+				// excluded identity still gets caught downstream by
+				// identityFilter below regardless of backend.
+				switch pluginMode {
+				case fallback.PluginModeEnvoyFilter:
+					fallbackFilterObj, err := fallback.BuildEnvoyFilter(fallback.EnvoyFilterParams{
+						Namespace:      istioNamespace,
+						Name:           fallbackName,
+						SelectorKey:    gatewaySelectorKey,
+						SelectorValue:  gatewaySelectorValue,
+						ResponseHeader: responseHeader,
+					})
+					if !assert.NoError(t, err) {
+						return ctx
+					}
+					if err := r.Create(ctx, fallbackFilterObj); !assert.NoError(t, err) {
+						return ctx
+					}
+				default:
+					fallbackWasmObj, err := fallback.BuildWasmPlugin(fallback.WasmPluginParams{
+						Namespace:          istioNamespace,
+						Name:               fallbackName,
+						SelectorKey:        gatewaySelectorKey,
+						SelectorValue:      gatewaySelectorValue,
+						MountPath:          dependencyLearnerMountPath,
+						WasmRelativePath:   dependencyLearnerWasmRelativePath,
+						ResponseHeader:     responseHeader,
+						ExcludedIdentities: []string{excludedIdentity},
+					})
+					if !assert.NoError(t, err) {
+						return ctx
+					}
+					if err := r.Create(ctx, fallbackWasmObj); !assert.NoError(t, err) {
+						return ctx
+					}
+				}
+
+				// redirect each namespace's mesh egress through the
+				// dependency-learner gateway
+				setupClientEgress := func(namespace string) error {
+					svcEntry := fallback.BuildClientServiceEntry(fallback.ClientEgressParams{
+						Namespace: namespace,
 						Name:      fallbackName,
-					},
-					Spec: extensionsv1alpha1.WasmPlugin{
-						Selector: &typev1beta1.WorkloadSelector{
-							MatchLabels: map[string]string{
-								gatewaySelectorKey: gatewaySelectorValue,
-							},
-						},
-						Url:          "file://" + path.Join(dependencyLearnerMountPath, dependencyLearnerWasmRelativePath),
-						Type:         extensionsv1alpha1.PluginType_HTTP,
-						Phase:        extensionsv1alpha1.PluginPhase_UNSPECIFIED_PHASE,
-						PluginConfig: pluginConfig,
-					},
+					})
+					if err := r.Create(ctx, svcEntry); err != nil {
+						return err
+					}
+					vs := fallback.BuildClientVirtualService(fallback.ClientEgressParams{
+						Namespace:   namespace,
+						Name:        fallbackName,
+						GatewayHost: fmt.Sprintf("%s.%s.svc.cluster.local", gatewayName, istioNamespace),
+						GatewayPort: 443,
+					})
+					return r.Create(ctx, vs)
+				}
+				if err := setupClientEgress(clientNamespace); !assert.NoError(t, err) {
+					return ctx
 				}
-				if err := r.Create(ctx, fallbackWasmObj); !assert.NoError(t, err) {
+				if err := setupClientEgress(excludedClientNamespace); !assert.NoError(t, err) {
+					return ctx
+				}
+				if err := setupClientEgress(deniedClientNamespace); !assert.NoError(t, err) {
 					return ctx
 				}
 
-				// setup fallback service entry
-				clientFallbackSvcEntryObj := &apinetworkingv1alpha3.ServiceEntry{
+				// deploy client
+				clientLabels := map[string]string{
+					"app": clientName,
+				}
+				clientReplicas := int32(1)
+				clientDeploymentObj := &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
+						Name:      clientName,
 						Namespace: clientNamespace,
-						Name:      fallbackName,
 					},
-					Spec: networkingv1alpha3.ServiceEntry{
-						Hosts: []string{
-							"*.svc",
-							"*.svc.cluster.local",
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: clientLabels,
 						},
-						Resolution: networkingv1alpha3.ServiceEntry_NONE,
-						ExportTo: []string{
-							clientNamespace,
+						Replicas: &clientReplicas,
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{
+								Labels: map[string]string{
+									"app": clientName,
+								},
+							},
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{
+									{
+										Name:  containerName,
+										Image: fmt.Sprintf("nginx:%s", nginxVersion),
+									},
+								},
+							},
 						},
 					},
 				}
-				if err := r.Create(ctx, clientFallbackSvcEntryObj); !assert.NoError(t, err) {
+				if err := r.Create(ctx, clientDeploymentObj); !assert.NoError(t, err) {
 					return ctx
 				}
 
-				// setup fallback virtual service
-				clientFallbackVsObj := &apinetworkingv1alpha3.VirtualService{
+				// deploy excluded client
+				excludedClientLabels := map[string]string{
+					"app": excludedClientName,
+				}
+				excludedClientReplicas := int32(1)
+				excludedClientDeploymentObj := &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      fallbackName,
-						Namespace: clientNamespace,
+						Name:      excludedClientName,
+						Namespace: excludedClientNamespace,
 					},
-					Spec: networkingv1alpha3.VirtualService{
-						Hosts: []string{
-							"*.svc",
-							"*.svc.cluster.local",
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{
+							MatchLabels: excludedClientLabels,
 						},
-						Http: []*networkingv1alpha3.HTTPRoute{
-							{
-								Route: []*networkingv1alpha3.HTTPRouteDestination{
+						Replicas: &excludedClientReplicas,
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{
+								Labels: excludedClientLabels,
+							},
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{
 									{
-										Destination: &networkingv1alpha3.Destination{
-											Host: fmt.Sprintf("%s.%s.svc.cluster.local", gatewayName, istioNamespace),
-											Port: &networkingv1alpha3.PortSelector{
-												Number: 443,
-											},
-										},
+										Name:  containerName,
+										Image: fmt.Sprintf("nginx:%s", nginxVersion),
 									},
 								},
 							},
 						},
-						Gateways: []string{"mesh"},
-						ExportTo: []string{"."},
 					},
 				}
-				if err = r.Create(ctx, clientFallbackVsObj); !assert.NoError(t, err) {
+				if err := r.Create(ctx, excludedClientDeploymentObj); !assert.NoError(t, err) {
 					return ctx
 				}
 
-				// deploy client
-				clientLabels := map[string]string{
-					"app": clientName,
+				// deploy denied client
+				deniedClientLabels := map[string]string{
+					"app": deniedClientName,
 				}
-				clientReplicas := int32(1)
-				clientDeploymentObj := &appsv1.Deployment{
+				deniedClientReplicas := int32(1)
+				deniedClientDeploymentObj := &appsv1.Deployment{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      clientName,
-						Namespace: clientNamespace,
+						Name:      deniedClientName,
+						Namespace: deniedClientNamespace,
 					},
 					Spec: appsv1.DeploymentSpec{
 						Selector: &metav1.LabelSelector{
-							MatchLabels: clientLabels,
+							MatchLabels: deniedClientLabels,
 						},
-						Replicas: &clientReplicas,
+						Replicas: &deniedClientReplicas,
 						Template: corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
-								Labels: map[string]string{
-									"app": clientName,
-								},
+								Labels: deniedClientLabels,
 							},
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -263,7 +332,7 @@ func TestDependencyLearner(t *testing.T) {
 						},
 					},
 				}
-				if err := r.Create(ctx, clientDeploymentObj); !assert.NoError(t, err) {
+				if err := r.Create(ctx, deniedClientDeploymentObj); !assert.NoError(t, err) {
 					return ctx
 				}
 
@@ -329,7 +398,7 @@ func TestDependencyLearner(t *testing.T) {
 					appsv1.DeploymentAvailable,
 					corev1.ConditionTrue,
 				), wait.WithContext(ctx))
-				if assert.NoError(t, err) {
+				if !assert.NoError(t, err) {
 					return ctx
 				}
 
@@ -339,10 +408,91 @@ func TestDependencyLearner(t *testing.T) {
 					appsv1.DeploymentAvailable,
 					corev1.ConditionTrue,
 				), wait.WithContext(ctx))
-				if assert.NoError(t, err) {
+				if !assert.NoError(t, err) {
 					return ctx
 				}
 
+				// wait for excluded client deployment
+				err = wait.For(conditions.New(r).DeploymentConditionMatch(
+					excludedClientDeploymentObj,
+					appsv1.DeploymentAvailable,
+					corev1.ConditionTrue,
+				), wait.WithContext(ctx))
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+
+				// wait for denied client deployment
+				err = wait.For(conditions.New(r).DeploymentConditionMatch(
+					deniedClientDeploymentObj,
+					appsv1.DeploymentAvailable,
+					corev1.ConditionTrue,
+				), wait.WithContext(ctx))
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+
+				// start the dependency controller against the hub cluster,
+				// which is where the canonical Dependency CRs live. the
+				// excluded client's identity is filtered defensively here
+				// too, in case an edge reaches the controller without going
+				// through the aggregator's own filtering. new Dependency CRs
+				// are created in ModeEnforce so policygen whitelists learned
+				// edges and denies everything else once it reconciles.
+				identityFilter := dependency.NewIdentityFilter(nil, []string{excludedIdentity})
+				edges := make(chan dependency.Edge)
+				ctrl := dependency.NewController(hubClient, dependencyNamespace, time.Second, identityFilter, dependencyv1alpha1.ModeEnforce)
+				runCtx, cancel := context.WithCancel(ctx)
+				go func() {
+					_ = ctrl.Run(runCtx, edges)
+				}()
+
+				// start the policy generator: it reads canonical Dependency
+				// resources from the hub and applies the AuthorizationPolicies
+				// it derives from them to the cluster under test, which is
+				// where the server workload they protect actually runs.
+				policyClient, err := ctrlclient.New(c.Client().RESTConfig(), ctrlclient.Options{Scheme: r.GetScheme()})
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				gen := policygen.NewGenerator(hubClient, policyClient, dependencyNamespace, time.Second)
+				go func() {
+					_ = gen.Run(runCtx)
+				}()
+
+				// register this cluster with the hub's secret controller by
+				// writing a kubeconfig secret, same as an operator would for
+				// a real remote mesh cluster
+				clusterKubeconfig, err := os.ReadFile(c.KubeconfigFile())
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				clusterName := "cluster-under-test"
+				clusterSecret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      clusterName,
+						Namespace: dependencyNamespace,
+						Labels:    map[string]string{multicluster.ClusterSecretLabel: "true"},
+					},
+					Data: map[string][]byte{clusterName: clusterKubeconfig},
+				}
+				if err := hubClient.Create(ctx, clusterSecret); !assert.NoError(t, err) {
+					return ctx
+				}
+
+				hubKubeClient, err := kubernetes.NewForConfig(mustRESTConfig(hubKubeconfig))
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				handler := multicluster.NewDependencyHandler(edges, nil, identityFilter)
+				secretCtrl := multicluster.NewSecretController(hubKubeClient, dependencyNamespace, r.GetScheme(), handler)
+				go func() {
+					_ = secretCtrl.Start(runCtx)
+				}()
+
+				ctx = context.WithValue(ctx, dependencyAggregatorCtxKey, handler)
+				ctx = context.WithValue(ctx, dependencyCancelCtxKey, cancel)
+
 				return ctx
 			},
 		).
@@ -374,25 +524,183 @@ func TestDependencyLearner(t *testing.T) {
 					return ctx
 				}
 
-				if !assert.Contains(t, stdoutStr, fmt.Sprintf(
-					"%s: spiffe://cluster.local/ns/%s/sa/default -> outbound|80||%s.%s.svc.cluster.local",
-					responseHeader, clientNamespace, serverName, serverNamespace,
-				)) {
+				headerValue := fmt.Sprintf(
+					"spiffe://cluster.local/ns/%s/sa/default -> outbound|80||%s.%s.svc.cluster.local",
+					clientNamespace, serverName, serverNamespace,
+				)
+				if !assert.Contains(t, stdoutStr, fmt.Sprintf("%s: %s", responseHeader, headerValue)) {
 					return ctx
 				}
 
+				// forward the observed header to the hub's shared aggregator,
+				// same as the access-log sidecar for this cluster would do
+				handler, ok := ctx.Value(dependencyAggregatorCtxKey).(*multicluster.DependencyHandler)
+				if !assert.True(t, ok, "dependency handler not found in context") {
+					return ctx
+				}
+				body := bytes.NewBufferString(fmt.Sprintf(`{"headerValue": %q}`, headerValue))
+				req := httptest.NewRequest("POST", "/edges", body)
+				rec := httptest.NewRecorder()
+				handler.Aggregator().ServeHTTP(rec, req)
+				assert.Equal(t, 202, rec.Code, "aggregator rejected edge: %s", rec.Body.String())
+
 				return ctx
 			},
 		).
 		Assess(
-			"check config map to see if dependency updated",
+			"excluded identity is not captured as a dependency",
 			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
-				t.Skip()
+				client, err := c.NewClient()
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+
+				pods := &corev1.PodList{}
+				if err := client.Resources(excludedClientNamespace).List(ctx, pods); !assert.NoError(t, err) ||
+					!assert.NotEmpty(t, pods.Items) {
+					return ctx
+				}
+				var stdout, stderr bytes.Buffer
+				podName := pods.Items[0].Name
+				command := []string{"curl", "-I", fmt.Sprintf("http://%s.%s.svc", serverName, serverNamespace)}
+				err = client.Resources().ExecInPod(ctx, excludedClientNamespace, podName, containerName, command, &stdout, &stderr)
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				stdoutStr := stdout.String()
+				t.Logf("got response from excluded client:\n%s", stdoutStr)
+
+				httpStatus := strings.Split(stdoutStr, "\n")[0]
+				if !assert.Contains(t, httpStatus, "200") {
+					return ctx
+				}
+
+				// the gateway's detection backend must recognize
+				// excludedIdentity and never set responseHeader at all, not
+				// just withhold it from the aggregator further downstream
+				assert.NotContains(t, stdoutStr, responseHeader+":",
+					"gateway should not have emitted %s for excluded identity %q", responseHeader, excludedIdentity)
+
+				// give any (incorrectly) published edge time to land, then
+				// assert no Dependency CR was created for it
+				time.Sleep(2 * time.Second)
+				deps := &dependencyv1alpha1.DependencyList{}
+				if !assert.NoError(t, hubClient.List(ctx, deps, ctrlclient.InNamespace(dependencyNamespace))) {
+					return ctx
+				}
+				for _, dep := range deps.Items {
+					assert.NotEqual(t, excludedIdentity, dep.Spec.SourceIdentity,
+						"excluded identity %q should not have a Dependency CR", excludedIdentity)
+				}
+
+				return ctx
+			},
+		).
+		Assess(
+			"check dependency CR reflects learned edge on the hub cluster",
+			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+				wantSource := fmt.Sprintf("spiffe://cluster.local/ns/%s/sa/default", clientNamespace)
+				wantDest := fmt.Sprintf("%s.%s.svc.cluster.local", serverName, serverNamespace)
+
+				var found *dependencyv1alpha1.Dependency
+				err := wait.For(func(ctx context.Context) (bool, error) {
+					deps := &dependencyv1alpha1.DependencyList{}
+					if err := hubClient.List(ctx, deps, ctrlclient.InNamespace(dependencyNamespace)); err != nil {
+						return false, err
+					}
+					for i := range deps.Items {
+						dep := &deps.Items[i]
+						if dep.Spec.SourceIdentity != wantSource {
+							continue
+						}
+						for _, dest := range dep.Spec.Destinations {
+							if dest.Host == wantDest && dest.Port == 80 {
+								found = dep
+								return true, nil
+							}
+						}
+					}
+					return false, nil
+				}, wait.WithContext(ctx), wait.WithTimeout(30*time.Second))
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				assert.NotNil(t, found)
+
+				return ctx
+			},
+		).
+		Assess(
+			"generated authorization policy allows the learned client but denies a new one",
+			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+				client, err := c.NewClient()
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+
+				// wait for policygen to reconcile the enforce-mode Dependency
+				// into an AuthorizationPolicy in the server's namespace
+				err = wait.For(func(waitCtx context.Context) (bool, error) {
+					policies := &apisecurityv1beta1.AuthorizationPolicyList{}
+					if err := client.Resources(serverNamespace).List(waitCtx, policies); err != nil {
+						return false, err
+					}
+					return len(policies.Items) > 0, nil
+				}, wait.WithContext(ctx), wait.WithTimeout(30*time.Second))
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+
+				// give istiod time to push the policy to the server's sidecar
+				time.Sleep(5 * time.Second)
+
+				statusCodeCommand := []string{
+					"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}",
+					fmt.Sprintf("http://%s.%s.svc", serverName, serverNamespace),
+				}
+
+				// the learned client should still be allowed through
+				clientPods := &corev1.PodList{}
+				if err := client.Resources(clientNamespace).List(ctx, clientPods); !assert.NoError(t, err) ||
+					!assert.NotEmpty(t, clientPods.Items) {
+					return ctx
+				}
+				var clientStdout, clientStderr bytes.Buffer
+				err = client.Resources().ExecInPod(
+					ctx, clientNamespace, clientPods.Items[0].Name, containerName,
+					statusCodeCommand, &clientStdout, &clientStderr,
+				)
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				assert.Equal(t, "200", clientStdout.String(), "learned client should still be allowed: %s", clientStderr.String())
+
+				// the new client, which never had an edge learned, should be
+				// denied once the enforce-mode policy is in effect
+				deniedPods := &corev1.PodList{}
+				if err := client.Resources(deniedClientNamespace).List(ctx, deniedPods); !assert.NoError(t, err) ||
+					!assert.NotEmpty(t, deniedPods.Items) {
+					return ctx
+				}
+				var deniedStdout, deniedStderr bytes.Buffer
+				err = client.Resources().ExecInPod(
+					ctx, deniedClientNamespace, deniedPods.Items[0].Name, containerName,
+					statusCodeCommand, &deniedStdout, &deniedStderr,
+				)
+				if !assert.NoError(t, err) {
+					return ctx
+				}
+				assert.Equal(t, "403", deniedStdout.String(), "new client should be denied: %s", deniedStderr.String())
+
 				return ctx
 			},
 		).
 		Teardown(
 			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+				if cancel, ok := ctx.Value(dependencyCancelCtxKey).(context.CancelFunc); ok {
+					cancel()
+				}
+
 				// create resources client
 				r, err := resources.New(c.Client().RESTConfig())
 				if !assert.NoError(t, err) {
@@ -414,13 +722,34 @@ func TestDependencyLearner(t *testing.T) {
 					},
 				}))
 
-				assert.NoError(t, r.Delete(ctx, &apiextensionsv1alpha1.WasmPlugin{
+				assert.NoError(t, r.Delete(ctx, &corev1.Namespace{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: istioNamespace,
-						Name:      fallbackName,
+						Name: excludedClientNamespace,
 					},
 				}))
 
+				assert.NoError(t, r.Delete(ctx, &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: deniedClientNamespace,
+					},
+				}))
+
+				if pluginMode == fallback.PluginModeEnvoyFilter {
+					assert.NoError(t, r.Delete(ctx, &apinetworkingv1alpha3.EnvoyFilter{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace: istioNamespace,
+							Name:      fallbackName,
+						},
+					}))
+				} else {
+					assert.NoError(t, r.Delete(ctx, &apiextensionsv1alpha1.WasmPlugin{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace: istioNamespace,
+							Name:      fallbackName,
+						},
+					}))
+				}
+
 				assert.NoError(t, r.Delete(ctx, &apinetworkingv1alpha3.VirtualService{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: istioNamespace,
@@ -434,6 +763,13 @@ func TestDependencyLearner(t *testing.T) {
 						Name:      fallbackName,
 					},
 				}))
+
+				assert.NoError(t, hubClient.Delete(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: dependencyNamespace,
+						Name:      "cluster-under-test",
+					},
+				}))
 				return ctx
 			},
 		).
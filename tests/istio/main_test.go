@@ -11,17 +11,47 @@ import (
 	apinetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	apitelemetryv1alpha1 "istio.io/client-go/pkg/apis/telemetry/v1alpha1"
 	istioscheme "istio.io/client-go/pkg/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/decoder"
 	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/pkg/env"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 	"sigs.k8s.io/e2e-framework/pkg/envfuncs"
 	"sigs.k8s.io/e2e-framework/support/kind"
 	"sigs.k8s.io/e2e-framework/third_party/helm"
+
+	dependencyv1alpha1 "github.com/wtzhang23/l7router/pkg/apis/dependency/v1alpha1"
+	"github.com/wtzhang23/l7router/pkg/istio/fallback"
 )
 
 var testenv env.Environment
 
+// pluginMode selects which dependency-learner detection backend
+// TestDependencyLearner exercises. It defaults to fallback.PluginModeWasm,
+// matching the gateway Helm install below (which always mounts the wasm
+// hostPath volume); set L7ROUTER_PLUGIN_MODE=envoyfilter to exercise the Lua
+// fallback instead.
+var pluginMode = func() fallback.PluginMode {
+	if os.Getenv("L7ROUTER_PLUGIN_MODE") == string(fallback.PluginModeEnvoyFilter) {
+		return fallback.PluginModeEnvoyFilter
+	}
+	return fallback.PluginModeWasm
+}()
+
+// hubClient and hubKubeconfig point at the second ("hub") kind cluster that
+// stores the canonical Dependency CRs aggregated across clusters. They are
+// populated during TestMain's Setup and read by TestDependencyLearner.
+var (
+	hubClient     ctrlclient.Client
+	hubKubeconfig []byte
+)
+
 const (
 	istioVersion                      string = "1.22.0"
 	istioNamespace                    string = "istio-system"
@@ -39,17 +69,91 @@ const (
 	dependencyLearnerWasmRelativePath string = "target/wasm32-wasi/release/dependency_learner.wasm"
 	dependencyLearnerVolumeName       string = "dependency-learner"
 	nginxVersion                      string = "1.25.5"
+	dependencyCRDDir                  string = "../../config/crd/bases"
+	dependencyCRDFile                 string = "dependency.l7router.io_dependencies.yaml"
+	dependencyNamespace               string = "l7router-system"
 )
 
 func TestMain(m *testing.M) {
 	testenv = env.New()
 	kindClusterName := envconf.RandomName("test-cluster-istio", 16)
+	kindHubClusterName := envconf.RandomName("test-cluster-hub", 16)
 
 	// create a kind cluster prior to test run
 	testenv.Setup(
 		envfuncs.CreateClusterWithConfig(kind.NewProvider(), kindClusterName, "./cluster.yaml"),
 		envfuncs.CreateNamespace(istioNamespace),
 
+		// create the second ("hub") cluster that stores the canonical
+		// Dependency CRs aggregated from every mesh cluster, and install the
+		// Dependency CRD there
+		envfuncs.CreateClusterWithConfig(kind.NewProvider(), kindHubClusterName, "./cluster.yaml"),
+		func(ctx context.Context, c *envconf.Config) (context.Context, error) {
+			hubKubeconfigFile, ok := ctx.Value(kindHubClusterName).(string)
+			if !ok {
+				return nil, fmt.Errorf("missing kubeconfig for hub cluster %q", kindHubClusterName)
+			}
+			var err error
+			hubKubeconfig, err = os.ReadFile(hubKubeconfigFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read hub cluster kubeconfig: %w", err)
+			}
+
+			hubConfig, err := clientcmd.BuildConfigFromFlags("", hubKubeconfigFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build hub cluster rest config: %w", err)
+			}
+
+			scheme := runtime.NewScheme()
+			if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+				return nil, fmt.Errorf("failed to add apiextensions resources to hub scheme: %w", err)
+			}
+			if err := dependencyv1alpha1.AddToScheme(scheme); err != nil {
+				return nil, fmt.Errorf("failed to add dependency resources to hub scheme: %w", err)
+			}
+			if err := corev1.AddToScheme(scheme); err != nil {
+				return nil, fmt.Errorf("failed to add core resources to hub scheme: %w", err)
+			}
+
+			hubClient, err = ctrlclient.New(hubConfig, ctrlclient.Options{Scheme: scheme})
+			if err != nil {
+				return nil, fmt.Errorf("failed to build hub cluster client: %w", err)
+			}
+
+			if err := hubClient.Create(ctx, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: dependencyNamespace},
+			}); err != nil {
+				return nil, fmt.Errorf("failed to create dependency namespace on hub cluster: %w", err)
+			}
+
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			if err := decoder.DecodeFile(
+				os.DirFS(dependencyCRDDir), dependencyCRDFile,
+				crd,
+			); err != nil {
+				return nil, fmt.Errorf("failed to decode dependency CRD: %w", err)
+			}
+			if err := hubClient.Create(ctx, crd); err != nil {
+				return nil, fmt.Errorf("failed to create dependency CRD on hub cluster: %w", err)
+			}
+			if err := wait.For(func(waitCtx context.Context) (bool, error) {
+				current := &apiextensionsv1.CustomResourceDefinition{}
+				if err := hubClient.Get(waitCtx, ctrlclient.ObjectKeyFromObject(crd), current); err != nil {
+					return false, err
+				}
+				for _, cond := range current.Status.Conditions {
+					if cond.Type == apiextensionsv1.Established && cond.Status == apiextensionsv1.ConditionTrue {
+						return true, nil
+					}
+				}
+				return false, nil
+			}, wait.WithContext(ctx)); err != nil {
+				return nil, fmt.Errorf("failed waiting for dependency CRD to be established on hub cluster: %w", err)
+			}
+
+			return ctx, nil
+		},
+
 		// load images to kind. images must be pulled locally for these to succeed
 		envfuncs.LoadDockerImageToCluster(kindClusterName, fmt.Sprintf("istio/proxyv2:%s", istioVersion)),
 		envfuncs.LoadDockerImageToCluster(kindClusterName, fmt.Sprintf("istio/pilot:%s", istioVersion)),
@@ -194,6 +298,7 @@ func TestMain(m *testing.M) {
 		},
 		envfuncs.DeleteNamespace(istioNamespace),
 		envfuncs.DestroyCluster(kindClusterName),
+		envfuncs.DestroyCluster(kindHubClusterName),
 	)
 
 	// launch package tests